@@ -1,11 +1,14 @@
 from fastapi import FastAPI
 from pydantic import BaseModel, Field
-from typing import List, Dict, Any
+from typing import List, Dict, Any, Protocol
+from collections import deque
+from dataclasses import dataclass, field
 import pandas as pd
 import numpy as np
 import yfinance as yf
 from datetime import datetime, timedelta
-import math
+import math, os, re, threading, time
+import httpx
 
 app = FastAPI(title="MoneyGrowAI Price & Risk Metrics")
 
@@ -102,6 +105,289 @@ def bubble_score(close: pd.Series) -> float:
     return float(max(0.0, min(1.0, score)))
 
 
+# ---------------- price providers ----------------
+# Symbol normalization mirrors ticker_discovery_service (rationale lives there);
+# each image ships a single file, so tests/test_symbol_sync.py keeps them equal.
+ALIASES = {
+    "BRKA": "BRK.A",
+    "BRKB": "BRK.B",
+}
+YAHOO_EXCHANGE_SUFFIXES = set(
+    """
+L DE F PA AS BR MI MC LS SW ST CO OL HE IR VI TO V NE AX NZ HK SS SZ T KS KQ
+TW SI NS BO JK BK KL SA MX
+""".split()
+)
+# Alpha Vantage names exchanges differently (TSCO.LON, SHOP.TRT); suffixes it
+# does not list are passed through and come back as unknown symbols.
+AV_EXCHANGES = {
+    "L": "LON",
+    "TO": "TRT",
+    "V": "TRV",
+    "DE": "DEX",
+    "BO": "BSE",
+    "SS": "SHH",
+    "SZ": "SHZ",
+}
+
+
+def normalize_symbol(sym: str) -> str:
+    """BRK.B / BRK-B / BRKB / $brk.b -> BRK.B; RCI-B.TO -> RCI.B.TO"""
+    s = (sym or "").strip().lstrip("$").upper()
+    s = re.sub(r"^([A-Z]{1,5})-([A-Z]{1,2})(\.[A-Z]{1,2})?$", r"\1.\2\3", s)
+    return ALIASES.get(s, s)
+
+
+def yahoo_symbol(sym: str) -> str:
+    """Canonical -> Yahoo format: BRK.B -> BRK-B, RCI.B.TO -> RCI-B.TO, SHEL.L."""
+    s = normalize_symbol(sym)
+    m = re.fullmatch(r"([A-Z]{1,5})\.([A-Z]{1,2})(\.[A-Z]{1,2})?", s)
+    if m and (m.group(3) or m.group(2) not in YAHOO_EXCHANGE_SUFFIXES):
+        return f"{m.group(1)}-{m.group(2)}{m.group(3) or ''}"
+    return s
+
+
+def av_symbol(sym: str) -> str:
+    """Canonical -> Alpha Vantage format: BRK.B -> BRK-B, SHEL.L -> SHEL.LON."""
+    base, dot, exchange = yahoo_symbol(sym).partition(".")
+    return f"{base}.{AV_EXCHANGES.get(exchange, exchange)}" if dot else base
+
+
+@dataclass
+class PriceBatch:
+    """Daily closes per ticker (indexed by date) plus per-ticker failures.
+
+    A ticker in neither closes nor errors simply has no data. Warnings flag
+    closes that were served but are degraded (e.g. truncated history).
+    """
+
+    closes: Dict[str, pd.Series] = field(default_factory=dict)
+    errors: Dict[str, str] = field(default_factory=dict)
+    warnings: Dict[str, str] = field(default_factory=dict)
+
+
+class PriceProvider(Protocol):
+    name: str
+    adjusted: bool  # closes adjusted for splits/dividends
+
+    def closes(self, tickers: List[str], period: str) -> PriceBatch: ...
+
+
+class YahooProvider:
+    name = "yahoo"
+    adjusted = True
+
+    def closes(self, tickers: List[str], period: str) -> PriceBatch:
+        out = PriceBatch()
+        symbols = {t: yahoo_symbol(t) for t in tickers}
+        try:
+            data = yf.download(
+                list(symbols.values()),
+                period=period,
+                interval="1d",
+                auto_adjust=True,
+                progress=False,
+                group_by="ticker",
+            )
+        except Exception as e:
+            out.errors = {t: str(e) for t in tickers}
+            return out
+
+        for t in tickers:
+            try:
+                # Normalize yfinance output shapes for 1/Many tickers
+                if isinstance(data.columns, pd.MultiIndex):
+                    if symbols[t] not in data.columns.get_level_values(0):
+                        continue
+                    close = data[symbols[t]]["Close"]
+                else:
+                    # single ticker comes flat
+                    close = data["Close"]
+                out.closes[t] = close.dropna()
+            except Exception as e:
+                out.errors[t] = str(e)
+        return out
+
+
+def period_start(period: str, today: datetime) -> datetime | None:
+    """yfinance period string -> first date to keep (None = all history)."""
+    p = period.strip().lower()
+    if p == "max":
+        return None
+    if p == "ytd":
+        return datetime(today.year, 1, 1)
+    m = re.fullmatch(r"(\d+)(d|wk|mo|y)", p)
+    if not m:
+        raise ValueError(f"unsupported period: {period}")
+    n, unit = int(m.group(1)), m.group(2)
+    days = {"d": 1, "wk": 7, "mo": 31, "y": 366}[unit] * n
+    return today - timedelta(days=days)
+
+
+class AlphaVantageError(RuntimeError):
+    """Non-data reply from Alpha Vantage (or the local budget running out).
+
+    kind is throttle, budget, premium, quota or apikey.
+    """
+
+    def __init__(self, kind: str, message: str):
+        super().__init__(f"alphavantage: {message}")
+        self.kind = kind
+
+
+class AlphaVantageProvider:
+    """TIME_SERIES_DAILY (unadjusted) closes, one request per ticker.
+
+    Splits inside the window show up as price gaps, so drawdown, volatility and
+    bubble metrics are distorted around them; responses report adjusted=false.
+
+    The free tier allows 5 requests/min. /metrics is synchronous, so rather than
+    sleeping through the window the provider keeps a process-wide budget of
+    requests_per_min per rolling minute: tickers past it (or after a throttling
+    reply) come back as errors to retry later. Requests stay 1s apart.
+    Full history is a premium feature: when it is refused the provider drops to
+    compact output (last 100 trading days) and flags the affected tickers.
+    """
+
+    name = "alphavantage"
+    adjusted = False  # TIME_SERIES_DAILY_ADJUSTED is premium
+    URL = "https://www.alphavantage.co/query"
+    COMPACT_DAYS = 140  # compact output = last 100 trading days
+    COMPACT_ONLY = "alphavantage: full history is premium; last 100 trading days only"
+    MIN_GAP = 1.0  # seconds; bursts faster than 1 request/s are throttled
+
+    def __init__(
+        self,
+        api_key: str,
+        requests_per_min: int = 5,
+        transport: httpx.BaseTransport | None = None,
+    ):
+        self.api_key = api_key
+        self.requests_per_min = max(1, requests_per_min)
+        self.transport = transport
+        self.full_available = True
+        self._lock = threading.Lock()
+        self._sent: deque[float] = deque()  # monotonic send times, last minute
+
+    def _get(self, client: httpx.Client, params: Dict[str, str]) -> Dict[str, Any]:
+        with self._lock:
+            now = time.monotonic()
+            while self._sent and now - self._sent[0] >= 60:
+                self._sent.popleft()
+            if len(self._sent) >= self.requests_per_min:
+                retry = math.ceil(60 - (now - self._sent[0]))
+                raise AlphaVantageError(
+                    "budget",
+                    f"{self.requests_per_min} requests/min used; retry in {retry}s",
+                )
+            if self._sent and now - self._sent[-1] < self.MIN_GAP:
+                time.sleep(self.MIN_GAP - (now - self._sent[-1]))
+            self._sent.append(time.monotonic())
+            r = client.get(self.URL, params=params)
+        r.raise_for_status()
+        return r.json()
+
+    @staticmethod
+    def _notice(d: Dict[str, Any]) -> tuple[str | None, str]:
+        """Classify a reply without data. Unknown symbols come back as (None, msg)."""
+        if "Error Message" in d:
+            msg = str(d["Error Message"])
+            return ("apikey" if "apikey" in msg.lower() else None), msg
+        msg = str(d.get("Note") or d.get("Information") or "")
+        low = msg.lower()
+        if not msg:
+            return None, ""
+        if any(k in low for k in ("per minute", "per second", "sparingly")):
+            return "throttle", msg
+        if "per day" in low:  # not "daily": premium notices name TIME_SERIES_DAILY
+            return "quota", msg
+        if "premium" in low:
+            return "premium", msg
+        return "throttle", msg  # unrecognised notices have always been rate limits
+
+    def _daily(self, client: httpx.Client, ticker: str, full: bool) -> Dict[str, Any]:
+        params = {
+            "function": "TIME_SERIES_DAILY",
+            "symbol": av_symbol(ticker),
+            "outputsize": "full" if full else "compact",
+            "apikey": self.api_key,
+        }
+        d = self._get(client, params)
+        kind, msg = self._notice(d)
+        if kind == "apikey":
+            raise AlphaVantageError(kind, f"check PRICE_API_KEY: {msg}")
+        if kind:
+            raise AlphaVantageError(kind, msg)
+        return d
+
+    def _close(
+        self, client: httpx.Client, ticker: str, start: datetime | None, full: bool
+    ) -> tuple[pd.Series | None, str | None]:
+        warning = None
+        if full and not self.full_available:
+            full, warning = False, self.COMPACT_ONLY
+        try:
+            d = self._daily(client, ticker, full)
+        except AlphaVantageError as e:
+            if e.kind != "premium" or not full:
+                raise
+            self.full_available = False
+            full, warning = False, self.COMPACT_ONLY
+            d = self._daily(client, ticker, full)
+
+        series = d.get("Time Series (Daily)")
+        if not series:  # unknown symbol -> "Error Message"
+            return None, None
+        rows = {pd.Timestamp(k): float(v["4. close"]) for k, v in series.items()}
+        close = pd.Series(rows, dtype=float).sort_index()
+        if start is not None:
+            close = close[close.index >= pd.Timestamp(start.date())]
+        return close.dropna(), warning
+
+    def closes(self, tickers: List[str], period: str) -> PriceBatch:
+        out = PriceBatch()
+        today = datetime.utcnow()
+        try:
+            start = period_start(period, today)
+        except ValueError as e:
+            out.errors = {t: str(e) for t in tickers}
+            return out
+        full = start is None or (today - start).days > self.COMPACT_DAYS
+
+        with httpx.Client(timeout=30, transport=self.transport) as client:
+            for i, t in enumerate(tickers):
+                try:
+                    close, warning = self._close(client, t, start, full)
+                    if close is not None:
+                        out.closes[t] = close
+                    if warning:
+                        out.warnings[t] = warning
+                except AlphaVantageError as e:
+                    if e.kind in ("apikey", "quota", "throttle", "budget"):
+                        # every remaining request would get the same answer
+                        out.errors.update({rest: str(e) for rest in tickers[i:]})
+                        break
+                    out.errors[t] = str(e)
+                except Exception as e:
+                    out.errors[t] = str(e)
+        return out
+
+
+def make_provider() -> PriceProvider:
+    name = os.getenv("PRICE_PROVIDER", "yahoo").strip().lower()
+    if name == "yahoo":
+        return YahooProvider()
+    if name == "alphavantage":
+        key = os.getenv("PRICE_API_KEY", "")
+        if not key:
+            raise RuntimeError("PRICE_PROVIDER=alphavantage requires PRICE_API_KEY")
+        return AlphaVantageProvider(key, int(os.getenv("PRICE_REQUESTS_PER_MIN", "5")))
+    raise RuntimeError(f"unknown PRICE_PROVIDER: {name}")
+
+
+provider: PriceProvider = make_provider()
+
+
 # ---------------- models ----------------
 class MetricsReq(BaseModel):
     tickers: List[str] = Field(..., example=["NVDA", "OXY", "AAPL"])
@@ -118,30 +404,20 @@ def health():
 @app.post("/metrics")
 def metrics(req: MetricsReq) -> Dict[str, Any]:
     out: Dict[str, Any] = {}
-    data = yf.download(
-        req.tickers,
-        period=req.period,
-        interval="1d",
-        auto_adjust=True,
-        progress=False,
-        group_by="ticker",
-    )
-    # Normalize yfinance output shapes for 1/Many tickers
-    tickers = [t.upper() for t in req.tickers]
+    # canonical symbols (BRK.B) so results join with ticker discovery output
+    tickers = list(dict.fromkeys(s for s in map(normalize_symbol, req.tickers) if s))
+    batch = provider.closes(tickers, req.period)
 
     for t in tickers:
         try:
-            if isinstance(data.columns, pd.MultiIndex):
-                df = data[t]["Close"].dropna().to_frame(name="Close")
-            else:
-                # single ticker comes flat
-                df = data["Close"].dropna().to_frame(name="Close")
-
-            if df.empty:
+            if t in batch.errors:
+                out[t] = {"ok": False, "error": batch.errors[t]}
+                continue
+            close = batch.closes.get(t)
+            if close is None or close.empty:
                 out[t] = {"ok": False, "error": "no_data"}
                 continue
 
-            close = df["Close"]
             returns = close.pct_change()
 
             # current & change
@@ -175,8 +451,15 @@ def metrics(req: MetricsReq) -> Dict[str, Any]:
                 "bubble_score": bubble_score(close),
                 "last_date": str(close.index[-1].date()),
             }
+            if t in batch.warnings:
+                m["warning"] = batch.warnings[t]
             out[t] = m
         except Exception as e:
             out[t] = {"ok": False, "error": str(e)}
 
-    return {"as_of": datetime.utcnow().isoformat() + "Z", "metrics": out}
+    return {
+        "as_of": datetime.utcnow().isoformat() + "Z",
+        "provider": provider.name,
+        "adjusted": provider.adjusted,
+        "metrics": out,
+    }
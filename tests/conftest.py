@@ -0,0 +1,46 @@
+import json
+import os
+import sys
+from pathlib import Path
+
+import pytest
+
+ROOT = Path(__file__).resolve().parent.parent
+FIXTURES = Path(__file__).resolve().parent / "fixtures"
+
+# Each service is a flat module run from its own folder (see the Dockerfiles),
+# so expose those folders for `import stock_metrics_service` and friends.
+SERVICES = ("article_extractor", "sentiment_api", "stock_metrics", "ticker_discovery")
+
+for service in SERVICES:
+    sys.path.insert(0, str(ROOT / service))
+
+
+@pytest.fixture
+def sample():
+    """Hand-written provider reply from fixtures/samples/ (JSON parsed, else a path).
+
+    Samples mirror the documented response shapes; they are not live captures.
+    """
+
+    def load(name: str):
+        path = FIXTURES / "samples" / name
+        return json.loads(path.read_text()) if path.suffix == ".json" else path
+
+    return load
+
+
+@pytest.fixture
+def golden():
+    """Compare parsed output with fixtures/golden/<name>.
+
+    Run with UPDATE_GOLDEN=1 to rewrite the file after an intended change.
+    """
+
+    def check(name: str, actual):
+        path = FIXTURES / "golden" / name
+        if os.getenv("UPDATE_GOLDEN"):
+            path.write_text(json.dumps(actual, indent=2, sort_keys=True) + "\n")
+        assert actual == json.loads(path.read_text())
+
+    return check
@@ -0,0 +1,44 @@
+from typing import Dict, List, Optional
+
+import pandas as pd
+import pytest
+
+import stock_metrics_service as sms
+
+
+class FakePriceProvider:
+    """In-memory PriceProvider for integration tests; records every request."""
+
+    name = "fake"
+    adjusted = True
+
+    def __init__(
+        self,
+        closes: Optional[Dict[str, pd.Series]] = None,
+        errors: Optional[Dict[str, str]] = None,
+        warnings: Optional[Dict[str, str]] = None,
+    ):
+        self.series = dict(closes or {})
+        self.errors = dict(errors or {})
+        self.warnings = dict(warnings or {})
+        self.requests: List[tuple] = []
+
+    def closes(self, tickers: List[str], period: str) -> sms.PriceBatch:
+        self.requests.append((list(tickers), period))
+
+        def pick(d):
+            return {t: v for t, v in d.items() if t in tickers}
+
+        return sms.PriceBatch(
+            closes=pick(self.series),
+            errors=pick(self.errors),
+            warnings=pick(self.warnings),
+        )
+
+
+@pytest.fixture
+def fake_prices(monkeypatch):
+    """Swap the service's provider for an empty FakePriceProvider."""
+    fake = FakePriceProvider()
+    monkeypatch.setattr(sms, "provider", fake)
+    return fake
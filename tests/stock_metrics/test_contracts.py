@@ -0,0 +1,70 @@
+"""Contract tests: sample provider replies through the real adapters."""
+
+import httpx
+import pandas as pd
+
+import stock_metrics_service as sms
+
+
+def as_dict(batch: sms.PriceBatch):
+    return {
+        t: {ts.date().isoformat(): float(v) for ts, v in close.items()}
+        for t, close in batch.closes.items()
+    }
+
+
+def test_alphavantage_daily_contract(sample, golden):
+    body = sample("alphavantage_daily_ibm.json")
+    seen = []
+
+    def handler(request: httpx.Request) -> httpx.Response:
+        seen.append(request)
+        return httpx.Response(200, json=body)
+
+    provider = sms.AlphaVantageProvider(
+        "KEY", requests_per_min=60_000, transport=httpx.MockTransport(handler)
+    )
+    batch = provider.closes(["IBM"], "max")
+
+    (request,) = seen
+    assert str(request.url.copy_with(query=None)) == provider.URL
+    assert dict(request.url.params) == {
+        "function": "TIME_SERIES_DAILY",
+        "symbol": "IBM",
+        "outputsize": "full",
+        "apikey": "KEY",
+    }
+    assert not batch.errors and not batch.warnings
+    golden("alphavantage_daily_ibm.json", as_dict(batch))
+
+
+def test_yahoo_download_contract(monkeypatch, sample, golden):
+    frame = pd.read_csv(
+        sample("yfinance_download_brk_nvda.csv"),
+        header=[0, 1],
+        index_col=0,
+        parse_dates=True,
+    )
+    calls = []
+
+    def download(tickers, **kwargs):
+        calls.append((tickers, kwargs))
+        return frame
+
+    monkeypatch.setattr(sms.yf, "download", download)
+    batch = sms.YahooProvider().closes(["BRK.B", "NVDA"], "5d")
+
+    assert calls == [
+        (
+            ["BRK-B", "NVDA"],
+            {
+                "period": "5d",
+                "interval": "1d",
+                "auto_adjust": True,
+                "progress": False,
+                "group_by": "ticker",
+            },
+        )
+    ]
+    assert not batch.errors
+    golden("yfinance_download_brk_nvda.json", as_dict(batch))
@@ -0,0 +1,291 @@
+from datetime import datetime, timedelta
+
+import httpx
+import pandas as pd
+import pytest
+from fastapi.testclient import TestClient
+
+import stock_metrics_service as sms
+
+THROTTLE = {
+    "Information": "Thank you for using Alpha Vantage! Please consider spreading "
+    "out your free API requests more sparingly (1 request per second)."
+}
+QUOTA = {
+    "Information": "We have detected your API key as DEMO and our standard API "
+    "rate limit is 25 requests per day. Please subscribe to any of the premium "
+    "plans at https://www.alphavantage.co/premium/ to instantly remove all daily "
+    "rate limits."
+}
+PREMIUM = {
+    "Information": "Thank you for using Alpha Vantage! The **outputsize=full** "
+    "parameter value is a premium feature for the TIME_SERIES_DAILY endpoint. You "
+    "may subscribe to any of the premium plans at "
+    "https://www.alphavantage.co/premium/ to instantly unlock all premium features"
+}
+BAD_KEY = {
+    "Error Message": "the parameter apikey is invalid or missing. Please claim your "
+    "free API key on (https://www.alphavantage.co/support/#api-key)."
+}
+UNKNOWN_SYMBOL = {
+    "Error Message": "Invalid API call. Please retry or visit the documentation "
+    "(https://www.alphavantage.co/documentation/) for TIME_SERIES_DAILY."
+}
+
+
+def daily(days: int, start_px: float = 100.0):
+    """TIME_SERIES_DAILY payload ending today, newest first like the real API."""
+    today = datetime.utcnow().date()
+    rows = {}
+    for i in range(days):
+        d = today - timedelta(days=i)
+        rows[d.isoformat()] = {"1. open": "0", "4. close": str(start_px + i)}
+    return {"Meta Data": {}, "Time Series (Daily)": rows}
+
+
+@pytest.fixture
+def sleeps(monkeypatch):
+    calls = []
+    monkeypatch.setattr(sms.time, "sleep", calls.append)
+    return calls
+
+
+def av(replies):
+    """Provider whose HTTP replies are served in order; records outputsize per call."""
+    seen = []
+
+    def handler(request: httpx.Request) -> httpx.Response:
+        params = request.url.params
+        seen.append((params["symbol"], params["outputsize"]))
+        return httpx.Response(200, json=replies.pop(0))
+
+    provider = sms.AlphaVantageProvider(
+        "KEY", requests_per_min=60_000, transport=httpx.MockTransport(handler)
+    )
+    return provider, seen
+
+
+@pytest.mark.parametrize(
+    "period,expected",
+    [
+        ("5d", datetime(2024, 6, 10)),
+        ("2wk", datetime(2024, 6, 1)),
+        ("6mo", datetime(2023, 12, 12)),
+        ("1y", datetime(2023, 6, 15)),
+        ("2Y", datetime(2022, 6, 14)),
+        (" ytd ", datetime(2024, 1, 1)),
+        ("max", None),
+    ],
+)
+def test_period_start(period, expected):
+    assert sms.period_start(period, datetime(2024, 6, 15)) == expected
+
+
+@pytest.mark.parametrize("period", ["", "1h", "two years", "10"])
+def test_period_start_rejects_unknown_periods(period):
+    with pytest.raises(ValueError):
+        sms.period_start(period, datetime(2024, 6, 15))
+
+
+def test_alphavantage_parses_sorted_closes(sleeps):
+    provider, seen = av([daily(10)])
+    batch = provider.closes(["IBM"], "1mo")
+
+    close = batch.closes["IBM"]
+    assert seen == [("IBM", "compact")]
+    assert close.index.is_monotonic_increasing
+    assert close.iloc[-1] == 100.0 and close.iloc[0] == 109.0
+    assert not batch.errors and not batch.warnings
+    assert provider.adjusted is False
+
+
+def test_alphavantage_requests_av_symbols_and_keys_canonical(sleeps):
+    provider, seen = av([daily(5), daily(5)])
+    batch = provider.closes(["BRK.B", "SHEL.L"], "1mo")
+
+    assert seen == [("BRK-B", "compact"), ("SHEL.LON", "compact")]
+    assert set(batch.closes) == {"BRK.B", "SHEL.L"}
+
+
+def test_alphavantage_trims_to_period(sleeps):
+    provider, _ = av([daily(30)])
+    close = provider.closes(["IBM"], "5d").closes["IBM"]
+
+    cutoff = datetime.utcnow().date() - timedelta(days=5)
+    assert len(close) == 6
+    assert all(ts.date() >= cutoff for ts in close.index)
+
+
+def test_alphavantage_throttle_fails_remaining_tickers_without_waiting(sleeps):
+    provider, seen = av([THROTTLE, daily(5)])
+    batch = provider.closes(["IBM", "MSFT"], "1mo")
+
+    assert set(batch.errors) == {"IBM", "MSFT"}
+    assert "per second" in batch.errors["MSFT"]
+    assert len(seen) == 1
+    assert not any(s >= 60 for s in sleeps)
+
+
+def test_alphavantage_tickers_past_the_minute_budget_are_errors(sleeps):
+    provider, seen = av([daily(5), daily(5)])
+    provider.requests_per_min = 2
+    batch = provider.closes(["IBM", "MSFT", "AAPL"], "1mo")
+
+    assert set(batch.closes) == {"IBM", "MSFT"}
+    assert "retry in" in batch.errors["AAPL"]
+    assert len(seen) == 2
+    assert not any(s >= 60 for s in sleeps)
+
+
+def test_alphavantage_falls_back_to_compact_when_full_is_premium(sleeps):
+    provider, seen = av([PREMIUM, daily(5), daily(5)])
+    batch = provider.closes(["IBM", "MSFT"], "2y")
+
+    assert seen == [("IBM", "full"), ("IBM", "compact"), ("MSFT", "compact")]
+    assert set(batch.closes) == {"IBM", "MSFT"}
+    assert batch.warnings == {
+        "IBM": provider.COMPACT_ONLY,
+        "MSFT": provider.COMPACT_ONLY,
+    }
+    assert not any(s >= 60 for s in sleeps)
+
+
+def test_alphavantage_daily_quota_fails_remaining_tickers_without_waiting(sleeps):
+    provider, seen = av([daily(5), QUOTA])
+    batch = provider.closes(["IBM", "MSFT", "AAPL"], "1mo")
+
+    assert "IBM" in batch.closes
+    assert set(batch.errors) == {"MSFT", "AAPL"}
+    assert "per day" in batch.errors["AAPL"]
+    assert len(seen) == 2
+    assert not any(s >= 60 for s in sleeps)
+
+
+def test_alphavantage_bad_key_is_a_configuration_error(sleeps):
+    provider, seen = av([BAD_KEY])
+    batch = provider.closes(["IBM", "MSFT"], "1mo")
+
+    assert set(batch.errors) == {"IBM", "MSFT"}
+    assert "PRICE_API_KEY" in batch.errors["IBM"]
+    assert len(seen) == 1
+
+
+def test_alphavantage_unknown_symbol_is_no_data(sleeps):
+    provider, _ = av([UNKNOWN_SYMBOL, daily(5)])
+    batch = provider.closes(["NOPE", "IBM"], "1mo")
+
+    assert "NOPE" not in batch.closes and "NOPE" not in batch.errors
+    assert "IBM" in batch.closes
+
+
+def test_alphavantage_http_error_stays_with_its_ticker(sleeps):
+    replies = [httpx.Response(500), httpx.Response(200, json=daily(5))]
+    transport = httpx.MockTransport(lambda request: replies.pop(0))
+    provider = sms.AlphaVantageProvider("KEY", 60_000, transport=transport)
+
+    batch = provider.closes(["IBM", "MSFT"], "1mo")
+    assert "500" in batch.errors["IBM"]
+    assert "MSFT" in batch.closes
+
+
+def test_alphavantage_unknown_period_errors_every_ticker_without_requests(sleeps):
+    provider, seen = av([])
+    batch = provider.closes(["IBM", "MSFT"], "forever")
+
+    assert set(batch.errors) == {"IBM", "MSFT"}
+    assert seen == []
+
+
+@pytest.mark.parametrize(
+    "sym,yahoo",
+    [
+        ("BRK.B", "BRK-B"),
+        ("RCI.B.TO", "RCI-B.TO"),
+        ("SHEL.L", "SHEL.L"),
+        ("NVDA", "NVDA"),
+        ("BTC-USD", "BTC-USD"),
+    ],
+)
+def test_yahoo_symbol(sym, yahoo):
+    assert sms.yahoo_symbol(sym) == yahoo
+
+
+@pytest.mark.parametrize(
+    "sym,av_sym",
+    [
+        ("BRK.B", "BRK-B"),
+        ("BRKB", "BRK-B"),
+        ("NVDA", "NVDA"),
+        ("SHEL.L", "SHEL.LON"),
+        ("SAP.DE", "SAP.DEX"),
+        ("RCI.B.TO", "RCI-B.TRT"),
+        ("ABC.V", "ABC.TRV"),
+        ("BHP.AX", "BHP.AX"),  # not listed by Alpha Vantage; passed through
+    ],
+)
+def test_av_symbol(sym, av_sym):
+    assert sms.av_symbol(sym) == av_sym
+
+
+def test_yahoo_provider_downloads_yahoo_symbols_and_keys_canonical(monkeypatch):
+    idx = pd.date_range("2024-01-01", periods=3, freq="D")
+    cols = pd.MultiIndex.from_product([["BRK-B", "NVDA"], ["Close"]])
+    rows = [[1.0, 10.0], [2.0, 20.0], [3.0, 30.0]]
+    frame = pd.DataFrame(rows, index=idx, columns=cols)
+    requested = []
+
+    def download(tickers, **kwargs):
+        requested.append(tickers)
+        return frame
+
+    monkeypatch.setattr(sms.yf, "download", download)
+    batch = sms.YahooProvider().closes(["BRK.B", "NVDA", "MISSING"], "1mo")
+
+    assert requested == [["BRK-B", "NVDA", "MISSING"]]
+    assert list(batch.closes["BRK.B"]) == [1.0, 2.0, 3.0]
+    assert list(batch.closes["NVDA"]) == [10.0, 20.0, 30.0]
+    assert "MISSING" not in batch.closes and not batch.errors
+
+
+def test_metrics_endpoint_with_fake_provider(fake_prices):
+    idx = pd.bdate_range("2023-01-02", periods=300)
+    # zig-zag uptrend: a strictly rising series has no down days and RSI is NaN
+    zigzag = [100.0 + i + (3 if i % 2 else 0) for i in range(300)]
+    fake_prices.series["AAA"] = pd.Series(zigzag, index=idx)
+    fake_prices.series["EMPTY"] = pd.Series([], dtype=float)
+    fake_prices.errors["ERR"] = "provider exploded"
+    fake_prices.warnings["AAA"] = "short history"
+
+    r = TestClient(sms.app).post(
+        "/metrics", json={"tickers": ["aaa", "ERR", "EMPTY", "NONE"], "period": "1y"}
+    )
+
+    assert r.status_code == 200
+    body = r.json()
+    assert fake_prices.requests == [(["AAA", "ERR", "EMPTY", "NONE"], "1y")]
+    assert body["provider"] == "fake" and body["adjusted"] is True
+
+    aaa = body["metrics"]["AAA"]
+    assert aaa["ok"] is True
+    assert aaa["current_price"] == 402.0
+    assert aaa["ret_1w"] == pytest.approx(402.0 / 394.0 - 1)
+    assert aaa["last_date"] == str(idx[-1].date())
+    assert aaa["warning"] == "short history"
+
+    assert body["metrics"]["ERR"] == {"ok": False, "error": "provider exploded"}
+    assert body["metrics"]["EMPTY"] == {"ok": False, "error": "no_data"}
+    assert body["metrics"]["NONE"] == {"ok": False, "error": "no_data"}
+
+
+def test_metrics_endpoint_keys_by_canonical_symbol(fake_prices):
+    idx = pd.bdate_range("2024-01-01", periods=30)
+    closes = [400.0 + i % 3 for i in range(30)]
+    fake_prices.series["BRK.B"] = pd.Series(closes, index=idx)
+
+    r = TestClient(sms.app).post(
+        "/metrics", json={"tickers": ["BRKB", "brk-b", "BRK.B"], "period": "1mo"}
+    )
+
+    assert r.status_code == 200
+    assert fake_prices.requests == [(["BRK.B"], "1mo")]
+    assert list(r.json()["metrics"]) == ["BRK.B"]
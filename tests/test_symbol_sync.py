@@ -0,0 +1,32 @@
+import pytest
+
+import stock_metrics_service as sms
+import ticker_discovery_service as tds
+
+# Both services ship as single files, so the symbol helpers are duplicated.
+SYMBOLS = [
+    "BRK.B",
+    "BRK-B",
+    "BRKB",
+    "brk.a",
+    "$BF-B",
+    "NVDA",
+    "SHEL.L",
+    "SAP.DE",
+    "RCI.B.TO",
+    "RCI-B.TO",
+    "BTC-USD",
+    "X.L",
+    "ABC.V",
+]
+
+
+def test_exchange_suffixes_match():
+    assert sms.YAHOO_EXCHANGE_SUFFIXES == tds.YAHOO_EXCHANGE_SUFFIXES
+    assert sms.ALIASES == tds.ALIASES
+
+
+@pytest.mark.parametrize("sym", SYMBOLS)
+def test_symbol_helpers_agree(sym):
+    assert sms.normalize_symbol(sym) == tds.normalize_symbol(sym)
+    assert sms.yahoo_symbol(sym) == tds.yahoo_symbol(sym)
@@ -0,0 +1,10 @@
+import pytest
+
+import ticker_discovery_service as tds
+
+
+@pytest.fixture(autouse=True)
+def empty_cache():
+    tds._cache.clear()
+    yield
+    tds._cache.clear()
@@ -0,0 +1,65 @@
+"""Contract tests: sample Yahoo search replies through the real parsing path."""
+
+import asyncio
+
+import httpx
+import pytest
+from fastapi.testclient import TestClient
+
+import ticker_discovery_service as tds
+
+SEARCH_URL = "https://query1.finance.yahoo.com/v1/finance/search"
+
+
+@pytest.fixture
+def yahoo(monkeypatch, sample):
+    """Answer every Yahoo search with the sample Berkshire reply."""
+    seen = []
+    body = sample("yahoo_search_berkshire.json")
+
+    def handler(request: httpx.Request) -> httpx.Response:
+        seen.append(request)
+        return httpx.Response(200, json=body)
+
+    real = httpx.AsyncClient
+    monkeypatch.setattr(
+        tds.httpx,
+        "AsyncClient",
+        lambda **kw: real(transport=httpx.MockTransport(handler), **kw),
+    )
+    return seen
+
+
+def test_yahoo_search_request_shape(yahoo):
+    asyncio.run(tds.verify_symbol("BRK.B"))
+
+    (request,) = yahoo
+    assert str(request.url.copy_with(query=None)) == SEARCH_URL
+    assert dict(request.url.params) == {
+        "q": "BRK-B",
+        "quotesCount": "5",
+        "newsCount": "0",
+    }
+    assert request.headers["User-Agent"] == "MoneyGrowAI/1.0"
+
+
+def test_yahoo_search_parsing_matches_golden(yahoo, golden):
+    verify = {s: asyncio.run(tds.verify_symbol(s)) for s in ("BRK.B", "BRK.A", "NVDA")}
+    resolve = asyncio.run(tds.resolve_name("berkshire"))
+    lookup = TestClient(tds.app).get("/lookup", params={"query": "berkshire"})
+
+    golden(
+        "yahoo_search_berkshire.json",
+        {"verify": verify, "resolve": resolve, "lookup": lookup.json()["results"]},
+    )
+
+
+def test_discover_n8n_verifies_against_sample_search(yahoo):
+    story = {"title": "Adding to $BRK-B on the dip", "url": "https://example.com/a"}
+    r = TestClient(tds.app).post("/discover_n8n", json={"stories": [story]})
+
+    body = r.json()
+    assert body["union_candidates"] == ["BRK", "BRK.B"]
+    assert body["allowed_tickers"] == ["BRK.B"]
+    assert body["per_story"][0]["allowed_tickers"] == ["BRK.B"]
+    assert [v["yahoo_symbol"] for v in body["verified"]] == ["BRK-B"]
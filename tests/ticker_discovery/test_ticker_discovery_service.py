@@ -0,0 +1,195 @@
+import asyncio
+
+import pytest
+from fastapi.testclient import TestClient
+
+import ticker_discovery_service as tds
+
+client = TestClient(tds.app)
+
+SEARCH = {
+    "quotes": [
+        {
+            "symbol": "AAPL",
+            "shortname": "Apple Inc.",
+            "longname": "Apple Inc.",
+            "exchDisp": "NASDAQ",
+            "quoteType": "EQUITY",
+        },
+        {"symbol": "AAPL250117C00100000", "quoteType": "OPTION"},
+        {"symbol": "APLE", "shortname": "Apple Hospitality", "quoteType": "EQUITY"},
+    ]
+}
+
+
+@pytest.fixture
+def searches(monkeypatch):
+    """Stub Yahoo search; returns the list of queries it received."""
+    seen = []
+
+    async def fake(q):
+        seen.append(q)
+        return SEARCH
+
+    monkeypatch.setattr(tds, "yahoo_search", fake)
+    return seen
+
+
+@pytest.mark.parametrize(
+    "raw,canonical",
+    [
+        ("BRK.B", "BRK.B"),
+        ("BRK-B", "BRK.B"),
+        ("brk-b", "BRK.B"),
+        ("BRKB", "BRK.B"),
+        ("$brk.b", "BRK.B"),
+        (" BRKA ", "BRK.A"),
+        ("BF-B", "BF.B"),
+        ("NVDA", "NVDA"),
+        ("SHEL.L", "SHEL.L"),
+        ("RCI-B.TO", "RCI.B.TO"),
+        ("RCI.B.TO", "RCI.B.TO"),
+        ("BTC-USD", "BTC-USD"),  # crypto pair, not a share class
+        ("", ""),
+    ],
+)
+def test_normalize_symbol(raw, canonical):
+    assert tds.normalize_symbol(raw) == canonical
+
+
+@pytest.mark.parametrize(
+    "sym,yahoo",
+    [
+        ("BRK.B", "BRK-B"),
+        ("BRKB", "BRK-B"),
+        ("BRK-B", "BRK-B"),
+        ("BF.B", "BF-B"),
+        ("NVDA", "NVDA"),
+        ("SHEL.L", "SHEL.L"),
+        ("SAP.DE", "SAP.DE"),
+        ("RCI.B.TO", "RCI-B.TO"),
+        ("BTC-USD", "BTC-USD"),
+        # ".L", ".T" and ".V" are exchanges first, even though they look like classes
+        ("X.L", "X.L"),
+        ("ABC.V", "ABC.V"),
+    ],
+)
+def test_yahoo_symbol(sym, yahoo):
+    assert tds.yahoo_symbol(sym) == yahoo
+
+
+@pytest.mark.parametrize(
+    "text,expected",
+    [
+        ("Loading up on $NVDA today", {"NVDA"}),
+        ("$NVDA-AI trade is crowded", {"NVDA"}),
+        ("$TSLA-GM merger chatter", {"TSLA"}),
+        ("Buffett's $BRK-B keeps compounding", {"BRK.B"}),
+        ("Buffett's $BRK.B keeps compounding", {"BRK.B"}),
+        ("London listing $SHEL.L", {"SHEL.L"}),
+        ("$AAPL, $MSFT. and $ETF", {"AAPL", "MSFT"}),  # ETF is a stop word
+        ("no tags here", set()),
+    ],
+)
+def test_detect_from_story_cashtags(text, expected):
+    out = tds.detect_from_story(tds.N8nStory(title=text))
+    cashtags = {c["symbol"] for c in out["candidates"] if "cashtag" in c["reasons"]}
+    assert cashtags == expected
+
+
+def test_detect_from_story_normalizes_action_lists():
+    story = tds.N8nStory(combined_text="Keep: BRK-B, NVDA")
+    out = tds.detect_from_story(story)
+
+    assert [t["ticker"] for t in out["tallies"]] == ["BRK.B", "NVDA"]
+    assert all(t["keep"] == 1 for t in out["tallies"])
+
+
+def test_lookup_filters_quote_types(searches):
+    r = client.get("/lookup", params={"query": "  apple  "})
+
+    assert r.status_code == 200
+    body = r.json()
+    assert body["ok"] is True and body["query"] == "apple"
+    assert [x["symbol"] for x in body["results"]] == ["AAPL", "APLE"]
+    assert body["results"][0] == {
+        "symbol": "AAPL",
+        "yahoo_symbol": "AAPL",
+        "shortname": "Apple Inc.",
+        "longname": "Apple Inc.",
+        "exchange": "NASDAQ",
+        "type": "EQUITY",
+    }
+    assert searches == ["apple"]
+
+
+def test_lookup_reports_provider_errors(monkeypatch):
+    async def boom(q):
+        raise RuntimeError("yahoo down")
+
+    monkeypatch.setattr(tds, "yahoo_search", boom)
+    r = client.get("/lookup", params={"query": "apple"})
+
+    assert r.status_code == 200
+    assert r.json() == {
+        "ok": False,
+        "query": "apple",
+        "error": "yahoo down",
+        "results": [],
+    }
+
+
+@pytest.mark.parametrize(
+    "params", [{}, {"query": ""}, {"query": "   "}, {"query": "x" * 65}]
+)
+def test_lookup_validates_query(searches, params):
+    r = client.get("/lookup", params=params)
+
+    assert r.status_code == 422
+    assert searches == []
+
+
+def test_cache_is_bounded_and_sweeps_expired(monkeypatch):
+    monkeypatch.setattr(tds, "_MAX_ENTRIES", 3)
+    for key in ("a", "b", "c"):
+        tds.cache_set(key, key)
+    tds._cache["a"]["ts"] -= tds._TTL + 1
+
+    tds.cache_set("d", "d")  # expired "a" is swept first
+    assert list(tds._cache) == ["b", "c", "d"]
+
+    tds.cache_set("e", "e")  # nothing expired: oldest entry goes
+    assert list(tds._cache) == ["c", "d", "e"]
+    assert tds.cache_get("b") is None and tds.cache_get("e") == "e"
+
+
+def test_yahoo_search_cache_key_ignores_case_and_spacing(monkeypatch):
+    calls = []
+
+    class FakeResponse:
+        def raise_for_status(self):
+            pass
+
+        def json(self):
+            return SEARCH
+
+    class FakeClient:
+        def __init__(self, **kwargs):
+            pass
+
+        async def __aenter__(self):
+            return self
+
+        async def __aexit__(self, *exc):
+            pass
+
+        async def get(self, url, params=None, headers=None):
+            calls.append(params["q"])
+            return FakeResponse()
+
+    monkeypatch.setattr(tds.httpx, "AsyncClient", FakeClient)
+    for q in ("Apple", "apple", "  APPLE "):
+        assert asyncio.run(tds.yahoo_search(q)) == SEARCH
+
+    assert calls == ["Apple"]
+    assert len(tds._cache) == 1
@@ -1,4 +1,4 @@
-from fastapi import FastAPI
+from fastapi import FastAPI, HTTPException, Query
 from pydantic import BaseModel
 from typing import List, Dict, Any, Optional
 import re, html, httpx, asyncio, time
@@ -13,12 +13,48 @@ NET FIG
 """.split()
 )  # add false-positive tokens you see often
 
-CASHTAG = re.compile(r"\$([A-Z]{1,5}(?:\.[A-Z]{1,2})?)\b")
+# NVDA, BRK.B, SHEL.L, and Yahoo's BRK-B. A hyphen only counts before a share
+# class letter so "$NVDA-AI" or "$TSLA-GM" still yield NVDA / TSLA.
+SYMBOL = r"[A-Z]{1,5}(?:\.[A-Z]{1,2}|-[A-C])?"
+CASHTAG = re.compile(r"\$(" + SYMBOL + r")\b")
 PAREN = re.compile(r"\(([A-Z]{1,5})(?::[A-Z]+)?\)")  # (OXY) or (RDS.A:NYSE)
 UPPERC = re.compile(r"\b([A-Z]{2,5})(?:\.[A-Z]{1,2})?\b")
-URL_TKR = re.compile(r"/(quote|symbol|ticker)/([A-Z]{1,5}(?:\.[A-Z]{1,2})?)")
+URL_TKR = re.compile(r"/(quote|symbol|ticker)/(" + SYMBOL + r")")
 ACTION = re.compile(r"(?i)^\s*(keep|cut|sell)\s*:\s*(.+)$")  # "Keep: NVDA, AMZN"
 
+# ----- symbol normalization -----
+# Canonical form uses "." for share classes (BRK.B, RCI.B.TO). Yahoo writes
+# classes with "-" (BRK-B, RCI-B.TO) but keeps "." for exchange suffixes
+# (SHEL.L), so both are mapped. A lone ".X" whose X is also an exchange code is
+# read as the exchange (SHEL.L), never as a class.
+# stock_metrics_service carries a copy; tests/test_symbol_sync.py keeps them equal.
+ALIASES = {
+    "BRKA": "BRK.A",
+    "BRKB": "BRK.B",
+}
+YAHOO_EXCHANGE_SUFFIXES = set(
+    """
+L DE F PA AS BR MI MC LS SW ST CO OL HE IR VI TO V NE AX NZ HK SS SZ T KS KQ
+TW SI NS BO JK BK KL SA MX
+""".split()
+)
+
+
+def normalize_symbol(sym: str) -> str:
+    """BRK.B / BRK-B / BRKB / $brk.b -> BRK.B; RCI-B.TO -> RCI.B.TO"""
+    s = (sym or "").strip().lstrip("$").upper()
+    s = re.sub(r"^([A-Z]{1,5})-([A-Z]{1,2})(\.[A-Z]{1,2})?$", r"\1.\2\3", s)
+    return ALIASES.get(s, s)
+
+
+def yahoo_symbol(sym: str) -> str:
+    """Canonical -> Yahoo format: BRK.B -> BRK-B, RCI.B.TO -> RCI-B.TO, SHEL.L."""
+    s = normalize_symbol(sym)
+    m = re.fullmatch(r"([A-Z]{1,5})\.([A-Z]{1,2})(\.[A-Z]{1,2})?", s)
+    if m and (m.group(3) or m.group(2) not in YAHOO_EXCHANGE_SUFFIXES):
+        return f"{m.group(1)}-{m.group(2)}{m.group(3) or ''}"
+    return s
+
 
 def _clean(s: str) -> str:
     return re.sub(r"\s+", " ", html.unescape(s or "")).strip()
@@ -29,8 +65,8 @@ def _split_symbols(s: str) -> List[str]:
     out = []
     for p in parts:
         p = p.strip().upper()
-        if re.fullmatch(r"[A-Z]{1,5}(?:\.[A-Z]{1,2})?", p):
-            out.append(p)
+        if re.fullmatch(SYMBOL, p):
+            out.append(normalize_symbol(p))
     return out
 
 
@@ -74,6 +110,7 @@ class ResolveReq(BaseModel):
 # ----- simple cache (per-process) -----
 _cache: Dict[str, Dict[str, Any]] = {}
 _TTL = 15 * 60  # 15 minutes
+_MAX_ENTRIES = 2048  # /lookup caches free-form autocomplete input
 
 
 def cache_get(key: str):
@@ -87,12 +124,21 @@ def cache_get(key: str):
 
 
 def cache_set(key: str, data: Any):
-    _cache[key] = {"ts": time.time(), "data": data}
+    now = time.time()
+    _cache.pop(key, None)
+    if len(_cache) >= _MAX_ENTRIES:
+        # sweep expired entries, then drop the oldest until there is room
+        for k in [k for k, v in _cache.items() if now - v["ts"] > _TTL]:
+            del _cache[k]
+        while len(_cache) >= _MAX_ENTRIES:
+            _cache.pop(next(iter(_cache)))
+    _cache[key] = {"ts": now, "data": data}
 
 
 # ----- Yahoo search (verification/mapping) -----
 async def yahoo_search(q: str) -> Dict[str, Any]:
-    key = f"yh:{q}"
+    q = " ".join(q.split())
+    key = f"yh:{q.lower()}"  # Yahoo search is case-insensitive
     hit = cache_get(key)
     if hit is not None:
         return hit
@@ -107,18 +153,27 @@ async def yahoo_search(q: str) -> Dict[str, Any]:
         return data
 
 
+QUOTE_TYPES = ("EQUITY", "ETF", "MUTUALFUND", "INDEX", "CRYPTO")
+
+
+def _quote_info(q: Dict[str, Any]) -> Dict[str, Any]:
+    return {
+        "symbol": normalize_symbol(str(q.get("symbol", ""))),
+        "yahoo_symbol": q.get("symbol"),
+        "shortname": q.get("shortname"),
+        "longname": q.get("longname"),
+        "exchange": q.get("exchDisp"),
+        "type": q.get("quoteType"),
+    }
+
+
 async def verify_symbol(sym: str) -> Optional[Dict[str, Any]]:
+    sym = normalize_symbol(sym)
     try:
-        d = await yahoo_search(sym)
+        d = await yahoo_search(yahoo_symbol(sym))
         for q in d.get("quotes", []):
-            if str(q.get("symbol", "")).upper() == sym.upper():
-                return {
-                    "symbol": q.get("symbol"),
-                    "shortname": q.get("shortname"),
-                    "longname": q.get("longname"),
-                    "exchange": q.get("exchDisp"),
-                    "type": q.get("quoteType"),
-                }
+            if normalize_symbol(str(q.get("symbol", ""))) == sym:
+                return _quote_info(q)
         return None
     except Exception:
         return None
@@ -128,14 +183,8 @@ async def resolve_name(name: str) -> Optional[Dict[str, Any]]:
     try:
         d = await yahoo_search(name)
         for q in d.get("quotes", []):
-            if q.get("quoteType") in ("EQUITY", "ETF", "MUTUALFUND", "INDEX", "CRYPTO"):
-                return {
-                    "symbol": q.get("symbol"),
-                    "shortname": q.get("shortname"),
-                    "longname": q.get("longname"),
-                    "exchange": q.get("exchDisp"),
-                    "type": q.get("quoteType"),
-                }
+            if q.get("quoteType") in QUOTE_TYPES:
+                return _quote_info(q)
         return None
     except Exception:
         return None
@@ -151,12 +200,12 @@ def detect_from_story(s: N8nStory) -> Dict[str, Any]:
     candidates: Dict[str, Dict[str, Any]] = {}
 
     def add(sym: str, reason: str):
-        sym = sym.upper()
+        sym = normalize_symbol(sym)
         if len(sym) > 6:  # crude guard
             return
         if sym in STOP:
             return
-        if not re.fullmatch(r"[A-Z]{1,5}(?:\.[A-Z]{1,2})?", sym):
+        if not re.fullmatch(SYMBOL, sym):
             return
         entry = candidates.get(sym) or {"symbol": sym, "reasons": set()}
         entry["reasons"].add(reason)
@@ -237,7 +286,7 @@ async def resolve(req: ResolveReq) -> Dict[str, Any]:
     Verify explicit tickers and map company names -> symbols.
     Returns a consolidated allowed_tickers list.
     """
-    tickers = sorted({t.upper() for t in (req.tickers or [])})
+    tickers = sorted({normalize_symbol(t) for t in (req.tickers or []) if t})
     names = sorted({n.strip() for n in (req.names or []) if n and n.strip()})
 
     verified, mapped = [], []
@@ -259,6 +308,28 @@ async def resolve(req: ResolveReq) -> Dict[str, Any]:
     }
 
 
+@app.get("/lookup")
+async def lookup(
+    query: str = Query(..., min_length=1, max_length=64),
+) -> Dict[str, Any]:
+    """
+    Instrument search for autocomplete (e.g. /lookup?query=apple).
+    Results come from the cached Yahoo search, filtered to tradable types.
+    """
+    q = " ".join(query.split())
+    if not q:
+        raise HTTPException(status_code=422, detail="query must not be blank")
+    try:
+        d = await yahoo_search(q)
+    except Exception as e:
+        return {"ok": False, "query": q, "error": str(e), "results": []}
+
+    results = [
+        _quote_info(x) for x in d.get("quotes", []) if x.get("quoteType") in QUOTE_TYPES
+    ]
+    return {"ok": True, "query": q, "results": results}
+
+
 @app.get("/health")
 def health():
     return {"ok": True}